@@ -0,0 +1,255 @@
+package jsoniter
+
+import (
+	"io"
+	"reflect"
+	"sync/atomic"
+	"unsafe"
+)
+
+// Config customizes how the API should behave. The zero value is a usable
+// configuration that behaves like ConfigDefault; set the fields you care
+// about and call Froze() to obtain an immutable API bound to that
+// configuration.
+type Config struct {
+	IndentionStep           int
+	MarshalFloatWith6Digits bool
+	EscapeHTML              bool
+	SortMapKeys             bool
+	UseNumber               bool
+	DisallowUnknownFields   bool
+	TagKey                  string
+	CaseSensitive           bool
+	OnlyTaggedField         bool
+	Fuzzy                   bool
+}
+
+// API is a frozen Config bound to its own decoder/encoder caches and
+// extension registry, so multiple configurations can coexist in the same
+// process without stepping on each other's state.
+type API interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+	NewEncoder(w io.Writer) *Encoder
+	NewDecoder(r io.Reader) *Decoder
+	BorrowIterator(data []byte) *Iterator
+	ReturnIterator(iter *Iterator)
+	RegisterExtension(extension ExtensionFunc)
+}
+
+// ConfigDefault is the API most users want: fastest common case, behaves
+// like the package-level functions did before Config existed.
+var ConfigDefault = Config{}.Froze()
+
+// ConfigCompatibleWithStandardLibrary tries to mimic the behavior of
+// encoding/json as closely as possible.
+var ConfigCompatibleWithStandardLibrary = Config{
+	EscapeHTML:  true,
+	SortMapKeys: true,
+}.Froze()
+
+// ConfigFastest trades strict JSON/Go compatibility for speed.
+var ConfigFastest = Config{
+	MarshalFloatWith6Digits: true,
+}.Froze()
+
+// ConfigCompatibleWithStandardLibraryButFuzzy behaves like
+// ConfigCompatibleWithStandardLibrary, but additionally coerces JSON
+// primitives across Go types instead of erroring (e.g. a JSON string into
+// an int field), the way many loosely-typed upstream APIs expect.
+var ConfigCompatibleWithStandardLibraryButFuzzy = Config{
+	EscapeHTML:  true,
+	SortMapKeys: true,
+	Fuzzy:       true,
+}.Froze()
+
+type frozenConfig struct {
+	configBeforeFrozen    Config
+	sortMapKeys           bool
+	indentionStep         int
+	tagKey                string
+	fuzzy                 bool
+	useNumber             bool
+	disallowUnknownFields bool
+	caseSensitive         bool
+	onlyTaggedField       bool
+	decoderCache          unsafe.Pointer // *map[reflect.Type]ValDecoder
+	encoderCache          unsafe.Pointer // *map[reflect.Type]ValEncoder
+	typeDecoders          map[string]ValDecoder
+	fieldDecoders         map[string]ValDecoder
+	typeEncoders          map[string]ValEncoder
+	fieldEncoders         map[string]ValEncoder
+	extensions            []ExtensionFunc
+	encoderExtensions     []EncoderExtension
+}
+
+// Froze binds this Config to a fresh, independent API instance.
+func (cfg Config) Froze() API {
+	api := &frozenConfig{
+		configBeforeFrozen:    cfg,
+		sortMapKeys:           cfg.SortMapKeys,
+		indentionStep:         cfg.IndentionStep,
+		tagKey:                cfg.TagKey,
+		fuzzy:                 cfg.Fuzzy,
+		useNumber:             cfg.UseNumber,
+		disallowUnknownFields: cfg.DisallowUnknownFields,
+		caseSensitive:         cfg.CaseSensitive,
+		onlyTaggedField:       cfg.OnlyTaggedField,
+		typeDecoders:          map[string]ValDecoder{},
+		fieldDecoders:         map[string]ValDecoder{},
+		typeEncoders:          map[string]ValEncoder{},
+		fieldEncoders:         map[string]ValEncoder{},
+		extensions:            []ExtensionFunc{},
+	}
+	if api.tagKey == "" {
+		api.tagKey = "json"
+	}
+	atomic.StorePointer(&api.decoderCache, unsafe.Pointer(&map[reflect.Type]ValDecoder{}))
+	atomic.StorePointer(&api.encoderCache, unsafe.Pointer(&map[reflect.Type]ValEncoder{}))
+	return api
+}
+
+func (cfg *frozenConfig) addDecoderToCache(cacheKey reflect.Type, decoder ValDecoder) {
+	retry := true
+	for retry {
+		ptr := atomic.LoadPointer(&cfg.decoderCache)
+		cache := *(*map[reflect.Type]ValDecoder)(ptr)
+		copied := map[reflect.Type]ValDecoder{}
+		for k, v := range cache {
+			copied[k] = v
+		}
+		copied[cacheKey] = decoder
+		retry = !atomic.CompareAndSwapPointer(&cfg.decoderCache, ptr, unsafe.Pointer(&copied))
+	}
+}
+
+func (cfg *frozenConfig) getDecoderFromCache(cacheKey reflect.Type) ValDecoder {
+	ptr := atomic.LoadPointer(&cfg.decoderCache)
+	cache := *(*map[reflect.Type]ValDecoder)(ptr)
+	return cache[cacheKey]
+}
+
+// RegisterExtension registers a custom extension against this API instance
+// only; it does not affect any other frozen Config.
+func (cfg *frozenConfig) RegisterExtension(extension ExtensionFunc) {
+	cfg.extensions = append(cfg.extensions, extension)
+}
+
+// Marshal encodes v using this API's configuration.
+func (cfg *frozenConfig) Marshal(v interface{}) ([]byte, error) {
+	stream := NewStream(cfg, nil, 256)
+	stream.WriteVal(v)
+	if stream.Error != nil {
+		return nil, stream.Error
+	}
+	return stream.Buffer(), nil
+}
+
+// Unmarshal decodes data into v using this API's configuration.
+func (cfg *frozenConfig) Unmarshal(data []byte, v interface{}) error {
+	iter := cfg.BorrowIterator(data)
+	defer cfg.ReturnIterator(iter)
+	iter.Read(v)
+	return iter.Error
+}
+
+// NewEncoder mirrors encoding/json.NewEncoder, writing successive JSON
+// values to w using this API's configuration.
+func (cfg *frozenConfig) NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{cfg: cfg, out: w}
+}
+
+// NewDecoder mirrors encoding/json.NewDecoder, reading successive JSON
+// values from r using this API's configuration.
+func (cfg *frozenConfig) NewDecoder(r io.Reader) *Decoder {
+	iter := Parse(r, 512)
+	iter.cfg = cfg
+	return &Decoder{cfg: cfg, iter: iter}
+}
+
+// Encoder writes successive JSON values to an output stream, mirroring
+// encoding/json.Encoder.
+type Encoder struct {
+	cfg *frozenConfig
+	out io.Writer
+}
+
+// Encode writes the JSON encoding of v to the stream, followed by a newline.
+func (enc *Encoder) Encode(v interface{}) error {
+	b, err := enc.cfg.Marshal(v)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	_, err = enc.out.Write(b)
+	return err
+}
+
+// Decoder reads successive JSON values from an input stream, mirroring
+// encoding/json.Decoder.
+type Decoder struct {
+	cfg  *frozenConfig
+	iter *Iterator
+}
+
+// Decode reads the next JSON-encoded value from its input and stores it in
+// v.
+func (dec *Decoder) Decode(v interface{}) error {
+	dec.iter.Read(v)
+	return dec.iter.Error
+}
+
+// BorrowIterator fetches an Iterator bound to this API's configuration and
+// caches from a pool, so repeated Unmarshal calls don't allocate.
+func (cfg *frozenConfig) BorrowIterator(data []byte) *Iterator {
+	iter := iteratorPool.Get().(*Iterator)
+	iter.ResetBytes(data)
+	iter.cfg = cfg
+	return iter
+}
+
+// ReturnIterator releases an Iterator obtained from BorrowIterator back to
+// the pool.
+func (cfg *frozenConfig) ReturnIterator(iter *Iterator) {
+	iter.cfg = nil
+	iteratorPool.Put(iter)
+}
+
+// Marshal is kept for backward compatibility; it delegates to ConfigDefault.
+func Marshal(v interface{}) ([]byte, error) {
+	return ConfigDefault.Marshal(v)
+}
+
+// MarshalToString is kept for backward compatibility; it delegates to
+// ConfigDefault.
+func MarshalToString(v interface{}) (string, error) {
+	data, err := ConfigDefault.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Unmarshal is kept for backward compatibility; it delegates to
+// ConfigDefault.
+func Unmarshal(data []byte, v interface{}) error {
+	return ConfigDefault.Unmarshal(data, v)
+}
+
+// UnmarshalFromString is kept for backward compatibility; it delegates to
+// ConfigDefault.
+func UnmarshalFromString(str string, v interface{}) error {
+	return ConfigDefault.Unmarshal([]byte(str), v)
+}
+
+// NewEncoder is kept for backward compatibility; it delegates to
+// ConfigDefault.
+func NewEncoder(w io.Writer) *Encoder {
+	return ConfigDefault.NewEncoder(w)
+}
+
+// NewDecoder is kept for backward compatibility; it delegates to
+// ConfigDefault.
+func NewDecoder(r io.Reader) *Decoder {
+	return ConfigDefault.NewDecoder(r)
+}
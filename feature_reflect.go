@@ -19,8 +19,10 @@ Reflection on value is avoided as we can, as the reflect.Value itself will alloc
 For a simple struct binding, it will be reflect.Value free and allocation free
 */
 
-// Decoder works like a father class for sub-type decoders
-type Decoder interface {
+// ValDecoder works like a father class for sub-type decoders. It used to be
+// named Decoder, but that name now belongs to the encoding/json-style
+// streaming Decoder exposed through API.NewDecoder.
+type ValDecoder interface {
 	decode(ptr unsafe.Pointer, iter *Iterator)
 }
 
@@ -35,74 +37,49 @@ func (decoder *funcDecoder) decode(ptr unsafe.Pointer, iter *Iterator) {
 	decoder.fun(ptr, iter)
 }
 
-var DECODERS unsafe.Pointer
-
-var typeDecoders map[string]Decoder
-var fieldDecoders map[string]Decoder
-var extensions []ExtensionFunc
-
-func init() {
-	typeDecoders = map[string]Decoder{}
-	fieldDecoders = map[string]Decoder{}
-	extensions = []ExtensionFunc{}
-	atomic.StorePointer(&DECODERS, unsafe.Pointer(&map[string]Decoder{}))
-}
-
-func addDecoderToCache(cacheKey reflect.Type, decoder Decoder) {
-	retry := true
-	for retry {
-		ptr := atomic.LoadPointer(&DECODERS)
-		cache := *(*map[reflect.Type]Decoder)(ptr)
-		copy := map[reflect.Type]Decoder{}
-		for k, v := range cache {
-			copy[k] = v
-		}
-		copy[cacheKey] = decoder
-		retry = !atomic.CompareAndSwapPointer(&DECODERS, ptr, unsafe.Pointer(&copy))
-	}
-}
-
-func getDecoderFromCache(cacheKey reflect.Type) Decoder {
-	ptr := atomic.LoadPointer(&DECODERS)
-	cache := *(*map[reflect.Type]Decoder)(ptr)
-	return cache[cacheKey]
-}
-
-// RegisterTypeDecoder can register a type for json object
+// RegisterTypeDecoder registers a type decoder against ConfigDefault, kept
+// for backward compatibility with code written before Config existed.
 func RegisterTypeDecoder(typ string, fun DecoderFunc) {
-	typeDecoders[typ] = &funcDecoder{fun}
+	configDefault := ConfigDefault.(*frozenConfig)
+	configDefault.typeDecoders[typ] = &funcDecoder{fun}
 }
 
-// RegisterFieldDecoder can register a type for json field
+// RegisterFieldDecoder registers a field decoder against ConfigDefault, kept
+// for backward compatibility with code written before Config existed.
 func RegisterFieldDecoder(typ string, field string, fun DecoderFunc) {
-	fieldDecoders[fmt.Sprintf("%s/%s", typ, field)] = &funcDecoder{fun}
+	configDefault := ConfigDefault.(*frozenConfig)
+	configDefault.fieldDecoders[fmt.Sprintf("%s/%s", typ, field)] = &funcDecoder{fun}
 }
 
-// RegisterExtension can register a custom extension
+// RegisterExtension registers a custom extension against ConfigDefault, kept
+// for backward compatibility with code written before Config existed.
 func RegisterExtension(extension ExtensionFunc) {
-	extensions = append(extensions, extension)
+	configDefault := ConfigDefault.(*frozenConfig)
+	configDefault.RegisterExtension(extension)
 }
 
-// CleanDecoders cleans decoders registered
+// CleanDecoders clears the type/field decoders registered against
+// ConfigDefault.
 func CleanDecoders() {
-	typeDecoders = map[string]Decoder{}
-	fieldDecoders = map[string]Decoder{}
+	configDefault := ConfigDefault.(*frozenConfig)
+	configDefault.typeDecoders = map[string]ValDecoder{}
+	configDefault.fieldDecoders = map[string]ValDecoder{}
 }
 
 type optionalDecoder struct {
-	valueType    reflect.Type
-	valueDecoder Decoder
+	valueDecoder ValDecoder
+	typeX        *ptrTypeX
 }
 
 func (decoder *optionalDecoder) decode(ptr unsafe.Pointer, iter *Iterator) {
 	if iter.ReadNil() {
-		*((*unsafe.Pointer)(ptr)) = nil
+		decoder.typeX.UnsafeSet(ptr, nil)
 	} else {
 		if *((*unsafe.Pointer)(ptr)) == nil {
 			// pointer to null, we have to allocate memory to hold the value
-			value := reflect.New(decoder.valueType)
-			decoder.valueDecoder.decode(unsafe.Pointer(value.Pointer()), iter)
-			*((*uintptr)(ptr)) = value.Pointer()
+			value := decoder.typeX.UnsafeNew()
+			decoder.valueDecoder.decode(value, iter)
+			decoder.typeX.UnsafeSet(ptr, value)
 		} else {
 			// reuse existing instance
 			decoder.valueDecoder.decode(*((*unsafe.Pointer)(ptr)), iter)
@@ -110,25 +87,49 @@ func (decoder *optionalDecoder) decode(ptr unsafe.Pointer, iter *Iterator) {
 	}
 }
 
+// numberAsInterfaceDecoder is interfaceDecoder's counterpart for
+// Config.UseNumber: a JSON number decodes to a Number rather than float64,
+// the way encoding/json's UseNumber option behaves. Every other JSON type
+// still falls through to interfaceDecoder.
+type numberAsInterfaceDecoder struct{}
+
+func (decoder *numberAsInterfaceDecoder) decode(ptr unsafe.Pointer, iter *Iterator) {
+	if iter.WhatIsNext() != Number {
+		(&interfaceDecoder{}).decode(ptr, iter)
+		return
+	}
+	*(*interface{})(ptr) = iter.ReadNumber()
+}
+
 type mapDecoder struct {
-	mapType      reflect.Type
-	elemType     reflect.Type
-	elemDecoder  Decoder
-	mapInterface emptyInterface
+	elemDecoder ValDecoder
+	typeX       *mapTypeX
 }
 
 func (decoder *mapDecoder) decode(ptr unsafe.Pointer, iter *Iterator) {
-	// dark magic to cast unsafe.Pointer back to interface{} using reflect.Type
-	mapInterface := decoder.mapInterface
-	mapInterface.word = ptr
-	realInterface := (*interface{})(unsafe.Pointer(&mapInterface))
-	realVal := reflect.ValueOf(*realInterface).Elem()
-
+	if *(*unsafe.Pointer)(ptr) == nil {
+		// map field is nil, allocate one to decode into
+		*(*unsafe.Pointer)(ptr) = *(*unsafe.Pointer)(decoder.typeX.UnsafeMakeMap())
+	}
 	for field := iter.ReadObject(); field != ""; field = iter.ReadObject() {
-		elem := reflect.New(decoder.elemType)
-		decoder.elemDecoder.decode(unsafe.Pointer(elem.Pointer()), iter)
-		// to put into map, we have to use reflection
-		realVal.SetMapIndex(reflect.ValueOf(string([]byte(field))), elem.Elem())
+		elemPtr := decoder.typeX.UnsafeNewElem()
+		decoder.elemDecoder.decode(elemPtr, iter)
+		key := string([]byte(field))
+		decoder.typeX.UnsafeSetMapIndex(ptr, unsafe.Pointer(&key), elemPtr)
+	}
+}
+
+type sliceDecoder struct {
+	elemDecoder ValDecoder
+	typeX       *sliceTypeX
+}
+
+func (decoder *sliceDecoder) decode(ptr unsafe.Pointer, iter *Iterator) {
+	header := (*sliceHeader)(ptr)
+	header.Len = 0
+	for iter.ReadArray() {
+		elemPtr := decoder.typeX.UnsafeGrowOne(ptr)
+		decoder.elemDecoder.decode(elemPtr, iter)
 	}
 }
 
@@ -242,17 +243,21 @@ func (iter *Iterator) readNumber() (ret *Any) {
 
 // Read converts an Iterator instance into go interface, same as json.Unmarshal
 func (iter *Iterator) Read(obj interface{}) {
+	cfg := iter.cfg
+	if cfg == nil {
+		cfg = ConfigDefault.(*frozenConfig)
+	}
 	typ := reflect.TypeOf(obj)
 	cacheKey := typ.Elem()
-	cachedDecoder := getDecoderFromCache(cacheKey)
+	cachedDecoder := cfg.getDecoderFromCache(cacheKey)
 	if cachedDecoder == nil {
-		decoder, err := decoderOfType(typ)
+		decoder, err := cfg.decoderOfType(typ)
 		if err != nil {
 			iter.Error = err
 			return
 		}
 		cachedDecoder = decoder
-		addDecoderToCache(cacheKey, decoder)
+		cfg.addDecoderToCache(cacheKey, decoder)
 	}
 	e := (*emptyInterface)(unsafe.Pointer(&obj))
 	cachedDecoder.decode(e.word, iter)
@@ -260,31 +265,39 @@ func (iter *Iterator) Read(obj interface{}) {
 
 type prefix string
 
-func (p prefix) addTo(decoder Decoder, err error) (Decoder, error) {
+func (p prefix) addTo(decoder ValDecoder, err error) (ValDecoder, error) {
 	if err != nil {
 		return nil, fmt.Errorf("%s: %s", p, err.Error())
 	}
 	return decoder, err
 }
 
-func decoderOfType(typ reflect.Type) (Decoder, error) {
+func (cfg *frozenConfig) decoderOfType(typ reflect.Type) (ValDecoder, error) {
 	switch typ.Kind() {
 	case reflect.Ptr:
-		return prefix("ptr").addTo(decoderOfPtr(typ.Elem()))
+		return prefix("ptr").addTo(cfg.decoderOfPtr(typ.Elem()))
 	default:
 		return nil, errors.New("expect ptr")
 	}
 }
 
-func decoderOfPtr(typ reflect.Type) (Decoder, error) {
+func (cfg *frozenConfig) decoderOfPtr(typ reflect.Type) (ValDecoder, error) {
 	typeName := typ.String()
 	if typeName == "jsoniter.Any" {
 		return &anyDecoder{}, nil
 	}
-	typeDecoder := typeDecoders[typeName]
+	typeDecoder := cfg.typeDecoders[typeName]
 	if typeDecoder != nil {
 		return typeDecoder, nil
 	}
+	if decoder := marshalerDecoderOf(typ); decoder != nil {
+		return decoder, nil
+	}
+	if cfg.fuzzy {
+		if decoder := fuzzyDecoderOfKind(typ.Kind()); decoder != nil {
+			return decoder, nil
+		}
+	}
 	switch typ.Kind() {
 	case reflect.String:
 		return &stringDecoder{}, nil
@@ -315,41 +328,43 @@ func decoderOfPtr(typ reflect.Type) (Decoder, error) {
 	case reflect.Bool:
 		return &boolDecoder{}, nil
 	case reflect.Interface:
+		if cfg.useNumber {
+			return &numberAsInterfaceDecoder{}, nil
+		}
 		return &interfaceDecoder{}, nil
 	case reflect.Struct:
-		return decoderOfStruct(typ)
+		return cfg.decoderOfStruct(typ)
 	case reflect.Slice:
-		return prefix("[slice]").addTo(decoderOfSlice(typ))
+		return prefix("[slice]").addTo(cfg.decoderOfSlice(typ))
 	case reflect.Map:
-		return prefix("[map]").addTo(decoderOfMap(typ))
+		return prefix("[map]").addTo(cfg.decoderOfMap(typ))
 	case reflect.Ptr:
-		return prefix("[optional]").addTo(decoderOfOptional(typ.Elem()))
+		return prefix("[optional]").addTo(cfg.decoderOfOptional(typ.Elem()))
 	default:
 		return nil, fmt.Errorf("unsupported type: %v", typ)
 	}
 }
 
-func decoderOfOptional(typ reflect.Type) (Decoder, error) {
-	decoder, err := decoderOfPtr(typ)
+func (cfg *frozenConfig) decoderOfOptional(typ reflect.Type) (ValDecoder, error) {
+	decoder, err := cfg.decoderOfPtr(typ)
 	if err != nil {
 		return nil, err
 	}
-	return &optionalDecoder{typ, decoder}, nil
+	return &optionalDecoder{decoder, newPtrTypeX(reflect.PtrTo(typ))}, nil
 }
 
-func decoderOfSlice(typ reflect.Type) (Decoder, error) {
-	decoder, err := decoderOfPtr(typ.Elem())
+func (cfg *frozenConfig) decoderOfSlice(typ reflect.Type) (ValDecoder, error) {
+	decoder, err := cfg.decoderOfPtr(typ.Elem())
 	if err != nil {
 		return nil, err
 	}
-	return &sliceDecoder{typ, typ.Elem(), decoder}, nil
+	return &sliceDecoder{decoder, newSliceTypeX(typ)}, nil
 }
 
-func decoderOfMap(typ reflect.Type) (Decoder, error) {
-	decoder, err := decoderOfPtr(typ.Elem())
+func (cfg *frozenConfig) decoderOfMap(typ reflect.Type) (ValDecoder, error) {
+	decoder, err := cfg.decoderOfPtr(typ.Elem())
 	if err != nil {
 		return nil, err
 	}
-	mapInterface := reflect.New(typ).Interface()
-	return &mapDecoder{typ, typ.Elem(), decoder, *((*emptyInterface)(unsafe.Pointer(&mapInterface)))}, nil
+	return &mapDecoder{decoder, newMapTypeX(typ)}, nil
 }
@@ -0,0 +1,474 @@
+package jsoniter
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"unsafe"
+)
+
+// ValEncoder is the encode-side counterpart to ValDecoder: one instance
+// knows how to write a single Go type to a Stream.
+type ValEncoder interface {
+	encode(ptr unsafe.Pointer, stream *Stream)
+	isEmpty(ptr unsafe.Pointer) bool
+}
+
+type EncoderFunc func(ptr unsafe.Pointer, stream *Stream)
+type EncoderExtension func(typ reflect.Type, field *reflect.StructField) ([]string, EncoderFunc)
+
+type funcEncoder struct {
+	fun EncoderFunc
+}
+
+func (encoder *funcEncoder) encode(ptr unsafe.Pointer, stream *Stream) {
+	encoder.fun(ptr, stream)
+}
+
+func (encoder *funcEncoder) isEmpty(ptr unsafe.Pointer) bool {
+	return false
+}
+
+// RegisterTypeEncoder registers a type encoder against ConfigDefault, kept
+// for backward compatibility with code written before Config existed.
+func RegisterTypeEncoder(typ string, fun EncoderFunc) {
+	configDefault := ConfigDefault.(*frozenConfig)
+	configDefault.typeEncoders[typ] = &funcEncoder{fun}
+}
+
+// RegisterFieldEncoder registers a field encoder against ConfigDefault, kept
+// for backward compatibility with code written before Config existed.
+func RegisterFieldEncoder(typ string, field string, fun EncoderFunc) {
+	configDefault := ConfigDefault.(*frozenConfig)
+	configDefault.fieldEncoders[fmt.Sprintf("%s/%s", typ, field)] = &funcEncoder{fun}
+}
+
+// RegisterEncoderExtension registers a custom encode-side extension against
+// ConfigDefault.
+func RegisterEncoderExtension(extension EncoderExtension) {
+	configDefault := ConfigDefault.(*frozenConfig)
+	configDefault.encoderExtensions = append(configDefault.encoderExtensions, extension)
+}
+
+func (cfg *frozenConfig) addEncoderToCache(cacheKey reflect.Type, encoder ValEncoder) {
+	retry := true
+	for retry {
+		ptr := atomic.LoadPointer(&cfg.encoderCache)
+		cache := *(*map[reflect.Type]ValEncoder)(ptr)
+		copied := map[reflect.Type]ValEncoder{}
+		for k, v := range cache {
+			copied[k] = v
+		}
+		copied[cacheKey] = encoder
+		retry = !atomic.CompareAndSwapPointer(&cfg.encoderCache, ptr, unsafe.Pointer(&copied))
+	}
+}
+
+func (cfg *frozenConfig) getEncoderFromCache(cacheKey reflect.Type) ValEncoder {
+	ptr := atomic.LoadPointer(&cfg.encoderCache)
+	cache := *(*map[reflect.Type]ValEncoder)(ptr)
+	return cache[cacheKey]
+}
+
+type optionalEncoder struct {
+	valueEncoder ValEncoder
+}
+
+func (encoder *optionalEncoder) encode(ptr unsafe.Pointer, stream *Stream) {
+	// ptr is the address of the pointer slot (struct field, slice/map
+	// element, or WriteVal's own copy of the value), not the pointee itself.
+	if *(*unsafe.Pointer)(ptr) == nil {
+		stream.WriteNil()
+		return
+	}
+	encoder.valueEncoder.encode(*(*unsafe.Pointer)(ptr), stream)
+}
+
+func (encoder *optionalEncoder) isEmpty(ptr unsafe.Pointer) bool {
+	return *(*unsafe.Pointer)(ptr) == nil
+}
+
+type sliceEncoder struct {
+	elemType    reflect.Type
+	elemEncoder ValEncoder
+}
+
+func (encoder *sliceEncoder) encode(ptr unsafe.Pointer, stream *Stream) {
+	header := (*sliceHeader)(ptr)
+	if header.Data == nil {
+		stream.WriteNil()
+		return
+	}
+	stream.WriteArrayStart()
+	elemSize := encoder.elemType.Size()
+	for i := 0; i < header.Len; i++ {
+		if i != 0 {
+			stream.WriteMore()
+		}
+		elemPtr := unsafe.Pointer(uintptr(header.Data) + uintptr(i)*elemSize)
+		encoder.elemEncoder.encode(elemPtr, stream)
+	}
+	stream.WriteArrayEnd()
+}
+
+func (encoder *sliceEncoder) isEmpty(ptr unsafe.Pointer) bool {
+	return (*sliceHeader)(ptr).Len == 0
+}
+
+type sliceHeader struct {
+	Data unsafe.Pointer
+	Len  int
+	Cap  int
+}
+
+type mapEncoder struct {
+	mapType      reflect.Type
+	elemType     reflect.Type
+	elemEncoder  ValEncoder
+	mapInterface emptyInterface
+}
+
+func (encoder *mapEncoder) encode(ptr unsafe.Pointer, stream *Stream) {
+	mapInterface := encoder.mapInterface
+	mapInterface.word = ptr
+	realInterface := (*interface{})(unsafe.Pointer(&mapInterface))
+	realVal := reflect.ValueOf(*realInterface).Elem()
+
+	keys := realVal.MapKeys()
+	if stream.cfg != nil && stream.cfg.sortMapKeys {
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+	}
+
+	stream.WriteObjectStart()
+	for i, key := range keys {
+		if i != 0 {
+			stream.WriteMore()
+		}
+		stream.WriteObjectField(fmt.Sprint(key.Interface()))
+		// realVal.MapIndex(key) is not addressable, so it cannot be handed
+		// to UnsafeAddr directly; copy it into an addressable value first.
+		elemCopy := reflect.New(encoder.elemType).Elem()
+		elemCopy.Set(realVal.MapIndex(key))
+		encoder.elemEncoder.encode(unsafe.Pointer(elemCopy.UnsafeAddr()), stream)
+	}
+	stream.WriteObjectEnd()
+}
+
+func (encoder *mapEncoder) isEmpty(ptr unsafe.Pointer) bool {
+	mapInterface := encoder.mapInterface
+	mapInterface.word = ptr
+	realInterface := (*interface{})(unsafe.Pointer(&mapInterface))
+	return reflect.ValueOf(*realInterface).Elem().Len() == 0
+}
+
+type structFieldEncoder struct {
+	field        *reflect.StructField
+	fieldEncoder ValEncoder
+	omitempty    bool
+}
+
+func (encoder *structFieldEncoder) encode(ptr unsafe.Pointer, stream *Stream) {
+	fieldPtr := unsafe.Pointer(uintptr(ptr) + encoder.field.Offset)
+	encoder.fieldEncoder.encode(fieldPtr, stream)
+}
+
+func (encoder *structFieldEncoder) isEmpty(ptr unsafe.Pointer) bool {
+	fieldPtr := unsafe.Pointer(uintptr(ptr) + encoder.field.Offset)
+	return encoder.fieldEncoder.isEmpty(fieldPtr)
+}
+
+type structEncoder struct {
+	fields []structFieldToEncode
+}
+
+type structFieldToEncode struct {
+	name    string
+	encoder *structFieldEncoder
+}
+
+func (encoder *structEncoder) encode(ptr unsafe.Pointer, stream *Stream) {
+	stream.WriteObjectStart()
+	wroteAny := false
+	for _, f := range encoder.fields {
+		if f.encoder.omitempty && f.encoder.isEmpty(ptr) {
+			continue
+		}
+		if wroteAny {
+			stream.WriteMore()
+		}
+		stream.WriteObjectField(f.name)
+		f.encoder.encode(ptr, stream)
+		wroteAny = true
+	}
+	stream.WriteObjectEnd()
+}
+
+func (encoder *structEncoder) isEmpty(ptr unsafe.Pointer) bool {
+	return len(encoder.fields) == 0
+}
+
+// encoderOfType is the entry point used by Stream.WriteVal: typ is the
+// dynamic type boxed in the interface{} passed to Marshal, which may itself
+// be a pointer type (handled by the reflect.Ptr case below).
+func (cfg *frozenConfig) encoderOfType(typ reflect.Type) (ValEncoder, error) {
+	typeName := typ.String()
+	typeEncoder := cfg.typeEncoders[typeName]
+	if typeEncoder != nil {
+		return typeEncoder, nil
+	}
+	if encoder := marshalerEncoderOf(typ); encoder != nil {
+		return encoder, nil
+	}
+	switch typ.Kind() {
+	case reflect.String:
+		return &stringEncoder{}, nil
+	case reflect.Int:
+		return &intEncoder{}, nil
+	case reflect.Int8:
+		return &int8Encoder{}, nil
+	case reflect.Int16:
+		return &int16Encoder{}, nil
+	case reflect.Int32:
+		return &int32Encoder{}, nil
+	case reflect.Int64:
+		return &int64Encoder{}, nil
+	case reflect.Uint:
+		return &uintEncoder{}, nil
+	case reflect.Uint8:
+		return &uint8Encoder{}, nil
+	case reflect.Uint16:
+		return &uint16Encoder{}, nil
+	case reflect.Uint32:
+		return &uint32Encoder{}, nil
+	case reflect.Uint64:
+		return &uint64Encoder{}, nil
+	case reflect.Float32:
+		return &float32Encoder{}, nil
+	case reflect.Float64:
+		return &float64Encoder{}, nil
+	case reflect.Bool:
+		return &boolEncoder{}, nil
+	case reflect.Interface:
+		return &dynamicEncoder{}, nil
+	case reflect.Struct:
+		return cfg.encoderOfStruct(typ)
+	case reflect.Slice:
+		return cfg.encoderOfSlice(typ)
+	case reflect.Map:
+		return cfg.encoderOfMap(typ)
+	case reflect.Ptr:
+		return cfg.encoderOfOptional(typ.Elem())
+	default:
+		return nil, fmt.Errorf("unsupported type: %v", typ)
+	}
+}
+
+// encoderOfPtr builds the encoder used when the value lives behind a
+// pointer, e.g. struct fields and slice/map elements reached via
+// unsafe.Pointer arithmetic rather than boxed in an interface{}.
+func (cfg *frozenConfig) encoderOfPtr(typ reflect.Type) (ValEncoder, error) {
+	return cfg.encoderOfType(typ)
+}
+
+func (cfg *frozenConfig) encoderOfOptional(typ reflect.Type) (ValEncoder, error) {
+	encoder, err := cfg.encoderOfPtr(typ)
+	if err != nil {
+		return nil, err
+	}
+	return &optionalEncoder{encoder}, nil
+}
+
+func (cfg *frozenConfig) encoderOfSlice(typ reflect.Type) (ValEncoder, error) {
+	encoder, err := cfg.encoderOfPtr(typ.Elem())
+	if err != nil {
+		return nil, err
+	}
+	return &sliceEncoder{typ.Elem(), encoder}, nil
+}
+
+func (cfg *frozenConfig) encoderOfMap(typ reflect.Type) (ValEncoder, error) {
+	encoder, err := cfg.encoderOfPtr(typ.Elem())
+	if err != nil {
+		return nil, err
+	}
+	mapInterface := reflect.New(typ).Interface()
+	return &mapEncoder{typ, typ.Elem(), encoder, *((*emptyInterface)(unsafe.Pointer(&mapInterface)))}, nil
+}
+
+func (cfg *frozenConfig) encoderOfStruct(typ reflect.Type) (ValEncoder, error) {
+	fields := []structFieldToEncode{}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get(cfg.tagKey)
+		if tag == "-" {
+			continue
+		}
+		fieldName, omitempty, asString := parseTag(tag, field.Name)
+		fieldEncoder, err := cfg.encoderOfPtr(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", field.Name, err.Error())
+		}
+		if asString {
+			fieldEncoder = &stringTaggedEncoder{fieldEncoder}
+		}
+		fieldCopy := field
+		fields = append(fields, structFieldToEncode{
+			name: fieldName,
+			encoder: &structFieldEncoder{
+				field:        &fieldCopy,
+				fieldEncoder: fieldEncoder,
+				omitempty:    omitempty,
+			},
+		})
+	}
+	return &structEncoder{fields}, nil
+}
+
+func parseTag(tag string, fieldName string) (name string, omitempty bool, asString bool) {
+	name = fieldName
+	if tag == "" {
+		return
+	}
+	parts := strings.Split(tag, ",")
+	if parts[0] != "" {
+		name = parts[0]
+	}
+	for _, opt := range parts[1:] {
+		switch opt {
+		case "omitempty":
+			omitempty = true
+		case "string":
+			asString = true
+		}
+	}
+	return
+}
+
+// stringTaggedEncoder implements the `json:",string"` option: the value is
+// encoded as its normal JSON representation, then re-quoted as a string.
+type stringTaggedEncoder struct {
+	valueEncoder ValEncoder
+}
+
+func (encoder *stringTaggedEncoder) encode(ptr unsafe.Pointer, stream *Stream) {
+	substream := NewStream(stream.cfg, nil, 16)
+	encoder.valueEncoder.encode(ptr, substream)
+	stream.WriteString(string(substream.Buffer()))
+}
+
+func (encoder *stringTaggedEncoder) isEmpty(ptr unsafe.Pointer) bool {
+	return encoder.valueEncoder.isEmpty(ptr)
+}
+
+type dynamicEncoder struct{}
+
+func (encoder *dynamicEncoder) encode(ptr unsafe.Pointer, stream *Stream) {
+	obj := *(*interface{})(ptr)
+	stream.WriteVal(obj)
+}
+
+func (encoder *dynamicEncoder) isEmpty(ptr unsafe.Pointer) bool {
+	return *(*interface{})(ptr) == nil
+}
+
+type stringEncoder struct{}
+
+func (encoder *stringEncoder) encode(ptr unsafe.Pointer, stream *Stream) {
+	stream.WriteString(*(*string)(ptr))
+}
+func (encoder *stringEncoder) isEmpty(ptr unsafe.Pointer) bool { return *(*string)(ptr) == "" }
+
+type boolEncoder struct{}
+
+func (encoder *boolEncoder) encode(ptr unsafe.Pointer, stream *Stream) {
+	stream.WriteBool(*(*bool)(ptr))
+}
+func (encoder *boolEncoder) isEmpty(ptr unsafe.Pointer) bool { return !*(*bool)(ptr) }
+
+type intEncoder struct{}
+
+func (encoder *intEncoder) encode(ptr unsafe.Pointer, stream *Stream) {
+	stream.WriteInt(*(*int)(ptr))
+}
+func (encoder *intEncoder) isEmpty(ptr unsafe.Pointer) bool { return *(*int)(ptr) == 0 }
+
+type int8Encoder struct{}
+
+func (encoder *int8Encoder) encode(ptr unsafe.Pointer, stream *Stream) {
+	stream.WriteInt8(*(*int8)(ptr))
+}
+func (encoder *int8Encoder) isEmpty(ptr unsafe.Pointer) bool { return *(*int8)(ptr) == 0 }
+
+type int16Encoder struct{}
+
+func (encoder *int16Encoder) encode(ptr unsafe.Pointer, stream *Stream) {
+	stream.WriteInt16(*(*int16)(ptr))
+}
+func (encoder *int16Encoder) isEmpty(ptr unsafe.Pointer) bool { return *(*int16)(ptr) == 0 }
+
+type int32Encoder struct{}
+
+func (encoder *int32Encoder) encode(ptr unsafe.Pointer, stream *Stream) {
+	stream.WriteInt32(*(*int32)(ptr))
+}
+func (encoder *int32Encoder) isEmpty(ptr unsafe.Pointer) bool { return *(*int32)(ptr) == 0 }
+
+type int64Encoder struct{}
+
+func (encoder *int64Encoder) encode(ptr unsafe.Pointer, stream *Stream) {
+	stream.WriteInt64(*(*int64)(ptr))
+}
+func (encoder *int64Encoder) isEmpty(ptr unsafe.Pointer) bool { return *(*int64)(ptr) == 0 }
+
+type uintEncoder struct{}
+
+func (encoder *uintEncoder) encode(ptr unsafe.Pointer, stream *Stream) {
+	stream.WriteUint(*(*uint)(ptr))
+}
+func (encoder *uintEncoder) isEmpty(ptr unsafe.Pointer) bool { return *(*uint)(ptr) == 0 }
+
+type uint8Encoder struct{}
+
+func (encoder *uint8Encoder) encode(ptr unsafe.Pointer, stream *Stream) {
+	stream.WriteUint8(*(*uint8)(ptr))
+}
+func (encoder *uint8Encoder) isEmpty(ptr unsafe.Pointer) bool { return *(*uint8)(ptr) == 0 }
+
+type uint16Encoder struct{}
+
+func (encoder *uint16Encoder) encode(ptr unsafe.Pointer, stream *Stream) {
+	stream.WriteUint16(*(*uint16)(ptr))
+}
+func (encoder *uint16Encoder) isEmpty(ptr unsafe.Pointer) bool { return *(*uint16)(ptr) == 0 }
+
+type uint32Encoder struct{}
+
+func (encoder *uint32Encoder) encode(ptr unsafe.Pointer, stream *Stream) {
+	stream.WriteUint32(*(*uint32)(ptr))
+}
+func (encoder *uint32Encoder) isEmpty(ptr unsafe.Pointer) bool { return *(*uint32)(ptr) == 0 }
+
+type uint64Encoder struct{}
+
+func (encoder *uint64Encoder) encode(ptr unsafe.Pointer, stream *Stream) {
+	stream.WriteUint64(*(*uint64)(ptr))
+}
+func (encoder *uint64Encoder) isEmpty(ptr unsafe.Pointer) bool { return *(*uint64)(ptr) == 0 }
+
+type float32Encoder struct{}
+
+func (encoder *float32Encoder) encode(ptr unsafe.Pointer, stream *Stream) {
+	stream.WriteFloat32(*(*float32)(ptr))
+}
+func (encoder *float32Encoder) isEmpty(ptr unsafe.Pointer) bool { return *(*float32)(ptr) == 0 }
+
+type float64Encoder struct{}
+
+func (encoder *float64Encoder) encode(ptr unsafe.Pointer, stream *Stream) {
+	stream.WriteFloat64(*(*float64)(ptr))
+}
+func (encoder *float64Encoder) isEmpty(ptr unsafe.Pointer) bool { return *(*float64)(ptr) == 0 }
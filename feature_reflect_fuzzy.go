@@ -0,0 +1,327 @@
+package jsoniter
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"unsafe"
+)
+
+/*
+Fuzzy decoders relax decoderOfPtr's normally strict per-kind dispatch so a
+JSON primitive can be coerced into a Go type that doesn't literally match,
+the way many loosely-typed upstream APIs (and some JSON producers) expect:
+
+  - JSON string  -> numeric: parsed as a number, "" -> zero value
+  - JSON number  -> string: written as the literal digits
+  - JSON bool    -> numeric: true -> 1, false -> 0
+  - JSON number  -> bool: 0 / 0.0 -> false, anything else -> true
+  - JSON null    -> zero value, for every scalar kind above (struct, slice,
+    map, pointer and interface kinds keep their strict decoders)
+  - JSON array of length 1 -> the element, decoded as the target scalar
+    type; length 0 -> zero value
+
+They are only selected by decoderOfPtr when Config.Fuzzy is set, and live
+in the requesting frozenConfig's own decoder cache, so a fuzzy API and a
+strict API can be frozen side by side without either one affecting the
+other's cache.
+*/
+
+// fuzzyDecoderOfKind returns the fuzzy decoder for a scalar reflect.Kind,
+// or nil if fuzzy decoding doesn't apply to that kind (e.g. Struct, Slice).
+func fuzzyDecoderOfKind(kind reflect.Kind) ValDecoder {
+	switch kind {
+	case reflect.String:
+		return &fuzzyStringDecoder{}
+	case reflect.Bool:
+		return &fuzzyBoolDecoder{}
+	case reflect.Int:
+		return &fuzzyIntDecoder{}
+	case reflect.Int8:
+		return &fuzzyInt8Decoder{}
+	case reflect.Int16:
+		return &fuzzyInt16Decoder{}
+	case reflect.Int32:
+		return &fuzzyInt32Decoder{}
+	case reflect.Int64:
+		return &fuzzyInt64Decoder{}
+	case reflect.Uint:
+		return &fuzzyUintDecoder{}
+	case reflect.Uint8:
+		return &fuzzyUint8Decoder{}
+	case reflect.Uint16:
+		return &fuzzyUint16Decoder{}
+	case reflect.Uint32:
+		return &fuzzyUint32Decoder{}
+	case reflect.Uint64:
+		return &fuzzyUint64Decoder{}
+	case reflect.Float32:
+		return &fuzzyFloat32Decoder{}
+	case reflect.Float64:
+		return &fuzzyFloat64Decoder{}
+	default:
+		return nil
+	}
+}
+
+func fuzzyReadInt64(iter *Iterator) int64 {
+	switch iter.WhatIsNext() {
+	case String:
+		str := iter.ReadString()
+		if str == "" {
+			return 0
+		}
+		val, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			iter.reportError("fuzzy decode int64", err.Error())
+			return 0
+		}
+		return val
+	case Number:
+		return iter.ReadInt64()
+	case Bool:
+		if iter.ReadBool() {
+			return 1
+		}
+		return 0
+	case Null:
+		iter.ReadNil()
+		return 0
+	case Array:
+		val := int64(0)
+		idx := 0
+		for iter.ReadArray() {
+			if idx == 0 {
+				val = fuzzyReadInt64(iter)
+			} else {
+				iter.Skip()
+			}
+			idx++
+		}
+		return val
+	default:
+		iter.reportError("fuzzy decode int64", fmt.Sprintf("unsupported type: %v", iter.WhatIsNext()))
+		return 0
+	}
+}
+
+func fuzzyReadUint64(iter *Iterator) uint64 {
+	switch iter.WhatIsNext() {
+	case String:
+		str := iter.ReadString()
+		if str == "" {
+			return 0
+		}
+		val, err := strconv.ParseUint(str, 10, 64)
+		if err != nil {
+			iter.reportError("fuzzy decode uint64", err.Error())
+			return 0
+		}
+		return val
+	case Number:
+		return iter.ReadUint64()
+	case Bool:
+		if iter.ReadBool() {
+			return 1
+		}
+		return 0
+	case Null:
+		iter.ReadNil()
+		return 0
+	case Array:
+		val := uint64(0)
+		idx := 0
+		for iter.ReadArray() {
+			if idx == 0 {
+				val = fuzzyReadUint64(iter)
+			} else {
+				iter.Skip()
+			}
+			idx++
+		}
+		return val
+	default:
+		iter.reportError("fuzzy decode uint64", fmt.Sprintf("unsupported type: %v", iter.WhatIsNext()))
+		return 0
+	}
+}
+
+func fuzzyReadFloat64(iter *Iterator) float64 {
+	switch iter.WhatIsNext() {
+	case String:
+		str := iter.ReadString()
+		if str == "" {
+			return 0
+		}
+		val, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			iter.reportError("fuzzy decode float64", err.Error())
+			return 0
+		}
+		return val
+	case Number:
+		return iter.ReadFloat64()
+	case Bool:
+		if iter.ReadBool() {
+			return 1
+		}
+		return 0
+	case Null:
+		iter.ReadNil()
+		return 0
+	case Array:
+		val := float64(0)
+		idx := 0
+		for iter.ReadArray() {
+			if idx == 0 {
+				val = fuzzyReadFloat64(iter)
+			} else {
+				iter.Skip()
+			}
+			idx++
+		}
+		return val
+	default:
+		iter.reportError("fuzzy decode float64", fmt.Sprintf("unsupported type: %v", iter.WhatIsNext()))
+		return 0
+	}
+}
+
+type fuzzyIntDecoder struct{}
+
+func (decoder *fuzzyIntDecoder) decode(ptr unsafe.Pointer, iter *Iterator) {
+	*((*int)(ptr)) = int(fuzzyReadInt64(iter))
+}
+
+type fuzzyInt8Decoder struct{}
+
+func (decoder *fuzzyInt8Decoder) decode(ptr unsafe.Pointer, iter *Iterator) {
+	*((*int8)(ptr)) = int8(fuzzyReadInt64(iter))
+}
+
+type fuzzyInt16Decoder struct{}
+
+func (decoder *fuzzyInt16Decoder) decode(ptr unsafe.Pointer, iter *Iterator) {
+	*((*int16)(ptr)) = int16(fuzzyReadInt64(iter))
+}
+
+type fuzzyInt32Decoder struct{}
+
+func (decoder *fuzzyInt32Decoder) decode(ptr unsafe.Pointer, iter *Iterator) {
+	*((*int32)(ptr)) = int32(fuzzyReadInt64(iter))
+}
+
+type fuzzyInt64Decoder struct{}
+
+func (decoder *fuzzyInt64Decoder) decode(ptr unsafe.Pointer, iter *Iterator) {
+	*((*int64)(ptr)) = fuzzyReadInt64(iter)
+}
+
+type fuzzyUintDecoder struct{}
+
+func (decoder *fuzzyUintDecoder) decode(ptr unsafe.Pointer, iter *Iterator) {
+	*((*uint)(ptr)) = uint(fuzzyReadUint64(iter))
+}
+
+type fuzzyUint8Decoder struct{}
+
+func (decoder *fuzzyUint8Decoder) decode(ptr unsafe.Pointer, iter *Iterator) {
+	*((*uint8)(ptr)) = uint8(fuzzyReadUint64(iter))
+}
+
+type fuzzyUint16Decoder struct{}
+
+func (decoder *fuzzyUint16Decoder) decode(ptr unsafe.Pointer, iter *Iterator) {
+	*((*uint16)(ptr)) = uint16(fuzzyReadUint64(iter))
+}
+
+type fuzzyUint32Decoder struct{}
+
+func (decoder *fuzzyUint32Decoder) decode(ptr unsafe.Pointer, iter *Iterator) {
+	*((*uint32)(ptr)) = uint32(fuzzyReadUint64(iter))
+}
+
+type fuzzyUint64Decoder struct{}
+
+func (decoder *fuzzyUint64Decoder) decode(ptr unsafe.Pointer, iter *Iterator) {
+	*((*uint64)(ptr)) = fuzzyReadUint64(iter)
+}
+
+type fuzzyFloat32Decoder struct{}
+
+func (decoder *fuzzyFloat32Decoder) decode(ptr unsafe.Pointer, iter *Iterator) {
+	*((*float32)(ptr)) = float32(fuzzyReadFloat64(iter))
+}
+
+type fuzzyFloat64Decoder struct{}
+
+func (decoder *fuzzyFloat64Decoder) decode(ptr unsafe.Pointer, iter *Iterator) {
+	*((*float64)(ptr)) = fuzzyReadFloat64(iter)
+}
+
+type fuzzyBoolDecoder struct{}
+
+func (decoder *fuzzyBoolDecoder) decode(ptr unsafe.Pointer, iter *Iterator) {
+	switch iter.WhatIsNext() {
+	case Bool:
+		*((*bool)(ptr)) = iter.ReadBool()
+	case Number:
+		*((*bool)(ptr)) = iter.ReadFloat64() != 0
+	case String:
+		str := iter.ReadString()
+		*((*bool)(ptr)) = str != "" && str != "0"
+	case Null:
+		iter.ReadNil()
+		*((*bool)(ptr)) = false
+	case Array:
+		idx := 0
+		val := false
+		for iter.ReadArray() {
+			if idx == 0 {
+				sub := &fuzzyBoolDecoder{}
+				sub.decode(unsafe.Pointer(&val), iter)
+			} else {
+				iter.Skip()
+			}
+			idx++
+		}
+		*((*bool)(ptr)) = val
+	default:
+		iter.reportError("fuzzyBoolDecoder", fmt.Sprintf("unsupported type: %v", iter.WhatIsNext()))
+	}
+}
+
+type fuzzyStringDecoder struct{}
+
+func (decoder *fuzzyStringDecoder) decode(ptr unsafe.Pointer, iter *Iterator) {
+	switch iter.WhatIsNext() {
+	case String:
+		*((*string)(ptr)) = iter.ReadString()
+	case Number:
+		*((*string)(ptr)) = string(iter.ReadNumber())
+	case Bool:
+		if iter.ReadBool() {
+			*((*string)(ptr)) = "true"
+		} else {
+			*((*string)(ptr)) = "false"
+		}
+	case Null:
+		iter.ReadNil()
+		*((*string)(ptr)) = ""
+	case Array:
+		idx := 0
+		val := ""
+		for iter.ReadArray() {
+			if idx == 0 {
+				sub := &fuzzyStringDecoder{}
+				sub.decode(unsafe.Pointer(&val), iter)
+			} else {
+				iter.Skip()
+			}
+			idx++
+		}
+		*((*string)(ptr)) = val
+	default:
+		iter.reportError("fuzzyStringDecoder", fmt.Sprintf("unsupported type: %v", iter.WhatIsNext()))
+	}
+}
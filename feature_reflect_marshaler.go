@@ -0,0 +1,125 @@
+package jsoniter
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"unsafe"
+)
+
+/*
+decoderOfPtr/encoderOfType used to dispatch purely on reflect.Kind, which
+meant a type implementing encoding/json.Unmarshaler (or
+encoding.TextUnmarshaler) was decoded field-by-field instead of through its
+own method, breaking drop-in compatibility with the standard library and
+with types vendored from upstream packages that rely on custom
+(un)marshalers. marshalerDecoderOf / marshalerEncoderOf are consulted before
+the per-kind switch and, when the type (or its pointer, since a
+pointer-receiver method only shows up on *T) implements one of these
+interfaces, return an adapter that slurps the JSON value as raw bytes and
+calls through to it.
+*/
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// marshalerDecoderOf returns an adapter decoder if typ or *typ implements
+// json.Unmarshaler or encoding.TextUnmarshaler, or nil otherwise. A pointer
+// typ also gets nil, so decoderOfPtr falls through to its reflect.Ptr case
+// and calls this again with the pointee type; a pointer-receiver method found
+// via ptrType below is then reached through reflect.NewAt(typ, ptr) returning
+// *typ directly, not **typ.
+func marshalerDecoderOf(typ reflect.Type) ValDecoder {
+	if typ.Kind() == reflect.Ptr {
+		return nil
+	}
+	ptrType := reflect.PtrTo(typ)
+	if typ.Implements(jsonUnmarshalerType) || ptrType.Implements(jsonUnmarshalerType) {
+		return &unmarshalerDecoder{typ}
+	}
+	if typ.Implements(textUnmarshalerType) || ptrType.Implements(textUnmarshalerType) {
+		return &textUnmarshalerDecoder{typ}
+	}
+	return nil
+}
+
+// marshalerEncoderOf returns an adapter encoder if typ or *typ implements
+// json.Marshaler or encoding.TextMarshaler, or nil otherwise. A pointer typ
+// also gets nil, so encoderOfType falls through to its reflect.Ptr case and
+// calls this again with the pointee type; a pointer-receiver method found via
+// ptrType below is then reached through reflect.NewAt(typ, ptr) returning
+// *typ directly, not **typ.
+func marshalerEncoderOf(typ reflect.Type) ValEncoder {
+	if typ.Kind() == reflect.Ptr {
+		return nil
+	}
+	ptrType := reflect.PtrTo(typ)
+	if typ.Implements(jsonMarshalerType) || ptrType.Implements(jsonMarshalerType) {
+		return &marshalerEncoder{typ}
+	}
+	if typ.Implements(textMarshalerType) || ptrType.Implements(textMarshalerType) {
+		return &textMarshalerEncoder{typ}
+	}
+	return nil
+}
+
+type unmarshalerDecoder struct {
+	valType reflect.Type
+}
+
+func (decoder *unmarshalerDecoder) decode(ptr unsafe.Pointer, iter *Iterator) {
+	unmarshaler := reflect.NewAt(decoder.valType, ptr).Interface().(json.Unmarshaler)
+	raw := iter.SkipAndReturnBytes()
+	if err := unmarshaler.UnmarshalJSON(raw); err != nil {
+		iter.reportError("unmarshalerDecoder", err.Error())
+	}
+}
+
+type textUnmarshalerDecoder struct {
+	valType reflect.Type
+}
+
+func (decoder *textUnmarshalerDecoder) decode(ptr unsafe.Pointer, iter *Iterator) {
+	unmarshaler := reflect.NewAt(decoder.valType, ptr).Interface().(encoding.TextUnmarshaler)
+	if err := unmarshaler.UnmarshalText([]byte(iter.ReadString())); err != nil {
+		iter.reportError("textUnmarshalerDecoder", err.Error())
+	}
+}
+
+type marshalerEncoder struct {
+	valType reflect.Type
+}
+
+func (encoder *marshalerEncoder) encode(ptr unsafe.Pointer, stream *Stream) {
+	marshaler := reflect.NewAt(encoder.valType, ptr).Interface().(json.Marshaler)
+	b, err := marshaler.MarshalJSON()
+	if err != nil {
+		stream.Error = err
+		return
+	}
+	stream.WriteRaw(string(b))
+}
+
+func (encoder *marshalerEncoder) isEmpty(ptr unsafe.Pointer) bool {
+	return false
+}
+
+type textMarshalerEncoder struct {
+	valType reflect.Type
+}
+
+func (encoder *textMarshalerEncoder) encode(ptr unsafe.Pointer, stream *Stream) {
+	marshaler := reflect.NewAt(encoder.valType, ptr).Interface().(encoding.TextMarshaler)
+	b, err := marshaler.MarshalText()
+	if err != nil {
+		stream.Error = err
+		return
+	}
+	stream.WriteString(string(b))
+}
+
+func (encoder *textMarshalerEncoder) isEmpty(ptr unsafe.Pointer) bool {
+	return false
+}
@@ -0,0 +1,207 @@
+package jsoniter
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unsafe"
+)
+
+/*
+decoderOfStruct builds a flat fieldName -> decoder map for a struct type,
+the decode-side counterpart of encoderOfStruct. Anonymous (embedded) fields
+are walked recursively and their fields promoted into the parent's JSON
+object, following the same visibility rules encoding/json uses: a
+shallower field wins over a deeper one with the same JSON name, and two
+fields at the same depth with the same name are dropped entirely rather
+than arbitrarily picking one. A pointer-to-struct embed is only allocated
+the first time one of its promoted fields actually shows up in the input.
+An explicit `json:"name"` tag on the embedded field itself opts out of
+promotion, decoding the embed as an ordinary nested object instead.
+
+Config.OnlyTaggedField drops any candidate without an explicit tag before a
+decoder is even built for it. Config.CaseSensitive controls whether a field
+name with no exact match falls back to a case-insensitive lookup, and
+Config.DisallowUnknownFields controls whether a field with no match at all
+(exact or case-insensitive) is an error instead of being skipped.
+*/
+
+type structDecoder struct {
+	fields                map[string]*structFieldDecoder
+	fieldsLower           map[string]*structFieldDecoder // non-nil only when !CaseSensitive
+	disallowUnknownFields bool
+}
+
+func (decoder *structDecoder) decode(ptr unsafe.Pointer, iter *Iterator) {
+	for field := iter.ReadObject(); field != ""; field = iter.ReadObject() {
+		fieldName := string([]byte(field))
+		fieldDecoder := decoder.fields[fieldName]
+		if fieldDecoder == nil && decoder.fieldsLower != nil {
+			fieldDecoder = decoder.fieldsLower[strings.ToLower(fieldName)]
+		}
+		if fieldDecoder == nil {
+			if decoder.disallowUnknownFields {
+				iter.reportError("ReadObject", "found unknown field: "+fieldName)
+				return
+			}
+			iter.Skip()
+			continue
+		}
+		fieldDecoder.decode(ptr, iter)
+	}
+}
+
+// fieldStep is one hop on the way from a struct's base address to a
+// (possibly promoted) field's address: an offset within the current
+// struct, optionally followed by dereferencing a pointer-to-struct embed,
+// allocating it on demand if it is still nil.
+type fieldStep struct {
+	offset   uintptr
+	indirect bool
+	typeX    *ptrTypeX
+}
+
+func (step fieldStep) apply(ptr unsafe.Pointer) unsafe.Pointer {
+	ptr = unsafe.Pointer(uintptr(ptr) + step.offset)
+	if step.indirect {
+		if *(*unsafe.Pointer)(ptr) == nil {
+			*(*unsafe.Pointer)(ptr) = step.typeX.UnsafeNew()
+		}
+		ptr = *(*unsafe.Pointer)(ptr)
+	}
+	return ptr
+}
+
+type structFieldDecoder struct {
+	path    []fieldStep
+	decoder ValDecoder
+}
+
+func (decoder *structFieldDecoder) decode(ptr unsafe.Pointer, iter *Iterator) {
+	for _, step := range decoder.path {
+		ptr = step.apply(ptr)
+	}
+	decoder.decoder.decode(ptr, iter)
+}
+
+// stringTaggedDecoder implements the `json:",string"` option on decode: the
+// field is written as a quoted string in the input, holding the value's
+// normal JSON representation.
+type stringTaggedDecoder struct {
+	cfg          *frozenConfig
+	valueDecoder ValDecoder
+}
+
+func (decoder *stringTaggedDecoder) decode(ptr unsafe.Pointer, iter *Iterator) {
+	str := iter.ReadString()
+	subIter := decoder.cfg.BorrowIterator([]byte(str))
+	defer decoder.cfg.ReturnIterator(subIter)
+	decoder.valueDecoder.decode(ptr, subIter)
+	if subIter.Error != nil {
+		iter.Error = subIter.Error
+	}
+}
+
+type structFieldCandidate struct {
+	depth int
+	path  []fieldStep
+	typ   reflect.Type
+	tag   reflect.StructTag
+}
+
+func (cfg *frozenConfig) decoderOfStruct(typ reflect.Type) (ValDecoder, error) {
+	candidates := map[string]*structFieldCandidate{}
+	ambiguous := map[string]int{}
+	if err := cfg.collectStructFields(typ, nil, 0, candidates, ambiguous); err != nil {
+		return nil, err
+	}
+	fields := map[string]*structFieldDecoder{}
+	var fieldsLower map[string]*structFieldDecoder
+	if !cfg.caseSensitive {
+		fieldsLower = map[string]*structFieldDecoder{}
+	}
+	for name, candidate := range candidates {
+		if _, isAmbiguous := ambiguous[name]; isAmbiguous {
+			continue
+		}
+		if cfg.onlyTaggedField && candidate.tag.Get(cfg.tagKey) == "" {
+			continue
+		}
+		fieldDecoder, err := cfg.decoderOfPtr(candidate.typ)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err.Error())
+		}
+		if _, _, asString := parseTag(candidate.tag.Get(cfg.tagKey), name); asString {
+			fieldDecoder = &stringTaggedDecoder{cfg, fieldDecoder}
+		}
+		structField := &structFieldDecoder{candidate.path, fieldDecoder}
+		fields[name] = structField
+		if fieldsLower != nil {
+			fieldsLower[strings.ToLower(name)] = structField
+		}
+	}
+	return &structDecoder{fields, fieldsLower, cfg.disallowUnknownFields}, nil
+}
+
+// collectStructFields walks typ's fields, recursing into anonymous structs
+// (and pointers to structs) so their fields are promoted into candidates
+// under the JSON name they would appear with at the top level. ambiguous
+// records the depth at which a name became ambiguous, so that a later field
+// reusing that name only resurrects it as a candidate if it is strictly
+// shallower than the depth the ambiguity was recorded at; a third field at
+// the same (or a deeper) depth must not un-ambiguate the name.
+func (cfg *frozenConfig) collectStructFields(
+	typ reflect.Type, path []fieldStep, depth int,
+	candidates map[string]*structFieldCandidate, ambiguous map[string]int,
+) error {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get(cfg.tagKey)
+		if tag == "-" {
+			continue
+		}
+		hasExplicitName := tag != "" && strings.Split(tag, ",")[0] != ""
+		fieldType := field.Type
+		indirect := false
+		if field.Anonymous && !hasExplicitName {
+			if fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+				indirect = true
+			}
+			if fieldType.Kind() == reflect.Struct {
+				step := fieldStep{offset: field.Offset, indirect: indirect}
+				if indirect {
+					step.typeX = newPtrTypeX(reflect.PtrTo(fieldType))
+				}
+				childPath := append(append([]fieldStep{}, path...), step)
+				if err := cfg.collectStructFields(fieldType, childPath, depth+1, candidates, ambiguous); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		name, _, _ := parseTag(tag, field.Name)
+		step := fieldStep{offset: field.Offset}
+		fieldPath := append(append([]fieldStep{}, path...), step)
+		existing, found := candidates[name]
+		ambiguousDepth, isAmbiguous := ambiguous[name]
+		switch {
+		case isAmbiguous && depth >= ambiguousDepth:
+			// name is already dropped at this depth; this field doesn't
+			// reach deep enough to reclaim it
+		case !found:
+			candidates[name] = &structFieldCandidate{depth: depth, path: fieldPath, typ: field.Type, tag: field.Tag}
+			delete(ambiguous, name)
+		case existing.depth < depth:
+			// shallower field already claimed this name, this one loses
+		case existing.depth == depth:
+			delete(candidates, name)
+			ambiguous[name] = depth
+		default:
+			// this field is shallower than the previous claim, it wins
+			candidates[name] = &structFieldCandidate{depth: depth, path: fieldPath, typ: field.Type, tag: field.Tag}
+			delete(ambiguous, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package jsoniter
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+/*
+typeX caches the runtime type pointer (the *rtype reflect keeps behind every
+reflect.Type) once at decoder-construction time, so the hot decode path can
+allocate and assign through unsafe.Pointer alone. This replaces the
+emptyInterface trick mapDecoder used to reconstruct an interface{} on every
+decode call, plus the reflect.New / reflect.Value.SetMapIndex pair, both of
+which allocate per key/value.
+
+There is one implementation per reflect.Kind that needs it: mapTypeX,
+ptrTypeX and sliceTypeX.
+*/
+type typeX interface {
+	Type() reflect.Type
+}
+
+// rtypeOf extracts the runtime type pointer backing a reflect.Type value,
+// the same two-word layout emptyInterface uses for interface{}.
+func rtypeOf(typ reflect.Type) unsafe.Pointer {
+	var asIface interface{} = typ
+	return (*emptyInterface)(unsafe.Pointer(&asIface)).word
+}
+
+//go:linkname unsafeNew reflect.unsafe_New
+func unsafeNew(rtype unsafe.Pointer) unsafe.Pointer
+
+//go:linkname makemap reflect.makemap
+func makemap(rtype unsafe.Pointer, cap int) unsafe.Pointer
+
+//go:linkname mapassign reflect.mapassign
+func mapassign(rtype unsafe.Pointer, m unsafe.Pointer, key, elem unsafe.Pointer)
+
+// mapTypeX operates on a map[K]V value given only a pointer to the map
+// header, without ever boxing it back into an interface{}.
+type mapTypeX struct {
+	rtype     unsafe.Pointer // *rtype of the map type itself
+	typ       reflect.Type
+	elemType  reflect.Type
+	elemRtype unsafe.Pointer // *rtype of the element type, for UnsafeNew
+}
+
+func newMapTypeX(typ reflect.Type) *mapTypeX {
+	return &mapTypeX{
+		rtype:     rtypeOf(typ),
+		typ:       typ,
+		elemType:  typ.Elem(),
+		elemRtype: rtypeOf(typ.Elem()),
+	}
+}
+
+func (t *mapTypeX) Type() reflect.Type { return t.typ }
+
+// UnsafeMakeMap allocates an empty map and returns a pointer to a map
+// variable holding it; the caller copies that variable's value into the
+// decode target's map field.
+func (t *mapTypeX) UnsafeMakeMap() unsafe.Pointer {
+	m := makemap(t.rtype, 0)
+	return unsafe.Pointer(&m)
+}
+
+// UnsafeNewElem allocates a zero value of the map's element type.
+func (t *mapTypeX) UnsafeNewElem() unsafe.Pointer {
+	return unsafeNew(t.elemRtype)
+}
+
+// UnsafeSetMapIndex assigns m[*key] = *elem without going through
+// reflect.Value.SetMapIndex. mapPtr must point at the map variable itself
+// (which holds the map header pointer), not at the header.
+func (t *mapTypeX) UnsafeSetMapIndex(mapPtr, keyPtr, elemPtr unsafe.Pointer) {
+	mapassign(t.rtype, *(*unsafe.Pointer)(mapPtr), keyPtr, elemPtr)
+}
+
+//go:linkname growslice runtime.growslice
+func growslice(oldPtr unsafe.Pointer, newLen, oldCap, num int, et unsafe.Pointer) sliceHeader
+
+// sliceTypeX grows a []T value given only a pointer to its header, without
+// boxing it back into an interface{} or going through
+// reflect.Value.SetLen/Append (both of which allocate a throwaway reflect.Value
+// per element).
+type sliceTypeX struct {
+	rtype    unsafe.Pointer // *rtype of the element type, for growslice
+	typ      reflect.Type
+	elemType reflect.Type
+	elemSize uintptr
+}
+
+func newSliceTypeX(typ reflect.Type) *sliceTypeX {
+	return &sliceTypeX{
+		rtype:    rtypeOf(typ.Elem()),
+		typ:      typ,
+		elemType: typ.Elem(),
+		elemSize: typ.Elem().Size(),
+	}
+}
+
+func (t *sliceTypeX) Type() reflect.Type { return t.typ }
+
+// UnsafeGrowOne grows the slice header at ptr by one element, reallocating
+// the backing array via runtime.growslice if it is already at capacity, and
+// returns a pointer to the newly available last element.
+func (t *sliceTypeX) UnsafeGrowOne(ptr unsafe.Pointer) unsafe.Pointer {
+	header := (*sliceHeader)(ptr)
+	if header.Len == header.Cap {
+		grown := growslice(header.Data, header.Len+1, header.Cap, 1, t.rtype)
+		header.Data = grown.Data
+		header.Cap = grown.Cap
+	}
+	header.Len++
+	return unsafe.Pointer(uintptr(header.Data) + uintptr(header.Len-1)*t.elemSize)
+}
+
+// ptrTypeX helps a pointer-typed field allocate its pointee lazily.
+type ptrTypeX struct {
+	elemRtype unsafe.Pointer
+	typ       reflect.Type
+}
+
+func newPtrTypeX(typ reflect.Type) *ptrTypeX {
+	return &ptrTypeX{elemRtype: rtypeOf(typ.Elem()), typ: typ}
+}
+
+func (t *ptrTypeX) Type() reflect.Type { return t.typ }
+
+// UnsafeNew allocates a zeroed instance of the pointee type and returns a
+// pointer to it, to be stored into the pointer field itself.
+func (t *ptrTypeX) UnsafeNew() unsafe.Pointer {
+	return unsafeNew(t.elemRtype)
+}
+
+// UnsafeSet stores val into the pointer-sized slot at ptr.
+func (t *ptrTypeX) UnsafeSet(ptr, val unsafe.Pointer) {
+	*(*unsafe.Pointer)(ptr) = val
+}
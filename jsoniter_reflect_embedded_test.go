@@ -0,0 +1,117 @@
+package jsoniter
+
+import (
+	"testing"
+
+	"github.com/json-iterator/go/require"
+)
+
+func Test_decode_embedded_struct_field_promotion(t *testing.T) {
+	should := require.New(t)
+	type Embedded struct {
+		Field1 string
+	}
+	type TestObject struct {
+		Embedded
+		Field2 string
+	}
+	obj := TestObject{}
+	should.Nil(UnmarshalFromString(`{"Field1":"hello","Field2":"world"}`, &obj))
+	should.Equal("hello", obj.Field1)
+	should.Equal("world", obj.Field2)
+}
+
+func Test_decode_embedded_ptr_struct_field_promotion(t *testing.T) {
+	should := require.New(t)
+	type Embedded struct {
+		Field1 string
+	}
+	type TestObject struct {
+		*Embedded
+		Field2 string
+	}
+	obj := TestObject{}
+	should.Nil(UnmarshalFromString(`{"Field1":"hello","Field2":"world"}`, &obj))
+	should.Equal("hello", obj.Field1)
+	should.Equal("world", obj.Field2)
+}
+
+func Test_decode_tagged_embed_is_not_promoted(t *testing.T) {
+	should := require.New(t)
+	type Embedded struct {
+		Field1 string
+	}
+	type TestObject struct {
+		Embedded `json:"embedded"`
+	}
+	obj := TestObject{}
+	should.Nil(UnmarshalFromString(`{"embedded":{"Field1":"hello"}}`, &obj))
+	should.Equal("hello", obj.Field1)
+}
+
+// Test_decode_shallower_field_wins_over_ambiguous_embeds exercises the case
+// where two embeds at the same depth promote the same field name (making it
+// ambiguous and dropping it), but a direct, strictly shallower field with
+// that name is declared afterwards: the direct field should win rather than
+// staying hidden behind the earlier ambiguity.
+func Test_decode_shallower_field_wins_over_ambiguous_embeds(t *testing.T) {
+	should := require.New(t)
+	type EmbeddedA struct {
+		X string
+	}
+	type EmbeddedB struct {
+		X string
+	}
+	type TestObject struct {
+		EmbeddedA
+		EmbeddedB
+		X string
+	}
+	obj := TestObject{}
+	should.Nil(UnmarshalFromString(`{"X":"direct"}`, &obj))
+	should.Equal("direct", obj.X)
+}
+
+func Test_decode_same_depth_ambiguous_field_is_dropped(t *testing.T) {
+	should := require.New(t)
+	type EmbeddedA struct {
+		X string
+	}
+	type EmbeddedB struct {
+		X string
+	}
+	type TestObject struct {
+		EmbeddedA
+		EmbeddedB
+	}
+	obj := TestObject{}
+	should.Nil(UnmarshalFromString(`{"X":"hello"}`, &obj))
+	should.Equal("", obj.EmbeddedA.X)
+	should.Equal("", obj.EmbeddedB.X)
+}
+
+// Test_decode_third_same_depth_embed_stays_ambiguous guards against a name
+// becoming ambiguous at one depth and then being wrongly resurrected by a
+// third embed reusing it at that same depth: it must stay dropped.
+func Test_decode_third_same_depth_embed_stays_ambiguous(t *testing.T) {
+	should := require.New(t)
+	type EmbeddedA struct {
+		X string
+	}
+	type EmbeddedB struct {
+		X string
+	}
+	type EmbeddedC struct {
+		X string
+	}
+	type TestObject struct {
+		EmbeddedA
+		EmbeddedB
+		EmbeddedC
+	}
+	obj := TestObject{}
+	should.Nil(UnmarshalFromString(`{"X":"hello"}`, &obj))
+	should.Equal("", obj.EmbeddedA.X)
+	should.Equal("", obj.EmbeddedB.X)
+	should.Equal("", obj.EmbeddedC.X)
+}
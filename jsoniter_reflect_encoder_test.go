@@ -0,0 +1,103 @@
+package jsoniter
+
+import (
+	"testing"
+
+	"github.com/json-iterator/go/require"
+)
+
+func Test_encode_int(t *testing.T) {
+	should := require.New(t)
+	str, err := MarshalToString(123)
+	should.Nil(err)
+	should.Equal("123", str)
+}
+
+func Test_encode_string(t *testing.T) {
+	should := require.New(t)
+	str, err := MarshalToString("hello")
+	should.Nil(err)
+	should.Equal(`"hello"`, str)
+}
+
+func Test_encode_slice(t *testing.T) {
+	should := require.New(t)
+	str, err := MarshalToString([]int{1, 2, 3})
+	should.Nil(err)
+	should.Equal("[1,2,3]", str)
+}
+
+func Test_encode_map(t *testing.T) {
+	should := require.New(t)
+	str, err := MarshalToString(map[string]int{"field1": 100})
+	should.Nil(err)
+	should.Equal(`{"field1":100}`, str)
+}
+
+func Test_encode_struct(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Field1 string
+		Field2 int
+	}
+	str, err := MarshalToString(TestObject{"hello", 100})
+	should.Nil(err)
+	should.Equal(`{"Field1":"hello","Field2":100}`, str)
+}
+
+func Test_encode_top_level_nil_ptr(t *testing.T) {
+	should := require.New(t)
+	var val *int
+	str, err := MarshalToString(val)
+	should.Nil(err)
+	should.Equal("null", str)
+}
+
+func Test_encode_top_level_non_nil_ptr(t *testing.T) {
+	should := require.New(t)
+	val := 100
+	str, err := MarshalToString(&val)
+	should.Nil(err)
+	should.Equal("100", str)
+}
+
+func Test_encode_struct_with_nil_ptr_field(t *testing.T) {
+	should := require.New(t)
+	type TestObject struct {
+		Field1 *string
+		Field2 *string
+	}
+	field2 := "world"
+	str, err := MarshalToString(TestObject{nil, &field2})
+	should.Nil(err)
+	should.Equal(`{"Field1":null,"Field2":"world"}`, str)
+}
+
+func Test_encode_slice_of_ptr(t *testing.T) {
+	should := require.New(t)
+	val1 := 1
+	val2 := 2
+	str, err := MarshalToString([]*int{&val1, nil, &val2})
+	should.Nil(err)
+	should.Equal("[1,null,2]", str)
+}
+
+func Test_encode_map_sorts_keys_when_configured(t *testing.T) {
+	should := require.New(t)
+	m := map[string]int{"c": 3, "a": 1, "b": 2}
+	data, err := ConfigCompatibleWithStandardLibrary.Marshal(m)
+	should.Nil(err)
+	should.Equal(`{"a":1,"b":2,"c":3}`, string(data))
+}
+
+func Test_encode_honors_indention_step(t *testing.T) {
+	should := require.New(t)
+	api := Config{IndentionStep: 2}.Froze()
+	type TestObject struct {
+		Field1 string
+		Field2 []int
+	}
+	data, err := api.Marshal(TestObject{"hello", []int{1, 2}})
+	should.Nil(err)
+	should.Equal("{\n  \"Field1\": \"hello\",\n  \"Field2\": [\n    1,\n    2\n  ]\n}", string(data))
+}
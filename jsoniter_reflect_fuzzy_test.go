@@ -0,0 +1,73 @@
+package jsoniter
+
+import (
+	"testing"
+
+	"github.com/json-iterator/go/require"
+)
+
+func Test_fuzzy_string_to_int(t *testing.T) {
+	should := require.New(t)
+	var val int
+	should.Nil(ConfigCompatibleWithStandardLibraryButFuzzy.Unmarshal([]byte(`"100"`), &val))
+	should.Equal(100, val)
+}
+
+func Test_fuzzy_empty_string_to_int(t *testing.T) {
+	should := require.New(t)
+	var val int
+	should.Nil(ConfigCompatibleWithStandardLibraryButFuzzy.Unmarshal([]byte(`""`), &val))
+	should.Equal(0, val)
+}
+
+func Test_fuzzy_number_to_string(t *testing.T) {
+	should := require.New(t)
+	var val string
+	should.Nil(ConfigCompatibleWithStandardLibraryButFuzzy.Unmarshal([]byte(`100`), &val))
+	should.Equal("100", val)
+}
+
+func Test_fuzzy_bool_to_int(t *testing.T) {
+	should := require.New(t)
+	var val int
+	should.Nil(ConfigCompatibleWithStandardLibraryButFuzzy.Unmarshal([]byte(`true`), &val))
+	should.Equal(1, val)
+}
+
+func Test_fuzzy_number_to_bool(t *testing.T) {
+	should := require.New(t)
+	var val bool
+	should.Nil(ConfigCompatibleWithStandardLibraryButFuzzy.Unmarshal([]byte(`0`), &val))
+	should.Equal(false, val)
+	should.Nil(ConfigCompatibleWithStandardLibraryButFuzzy.Unmarshal([]byte(`1.5`), &val))
+	should.Equal(true, val)
+}
+
+func Test_fuzzy_single_element_array_to_int(t *testing.T) {
+	should := require.New(t)
+	var val int
+	should.Nil(ConfigCompatibleWithStandardLibraryButFuzzy.Unmarshal([]byte(`[100]`), &val))
+	should.Equal(100, val)
+}
+
+func Test_fuzzy_empty_array_to_int(t *testing.T) {
+	should := require.New(t)
+	var val int
+	should.Nil(ConfigCompatibleWithStandardLibraryButFuzzy.Unmarshal([]byte(`[]`), &val))
+	should.Equal(0, val)
+}
+
+func Test_fuzzy_null_to_int(t *testing.T) {
+	should := require.New(t)
+	val := 100
+	should.Nil(ConfigCompatibleWithStandardLibraryButFuzzy.Unmarshal([]byte(`null`), &val))
+	should.Equal(0, val)
+}
+
+func Test_non_fuzzy_string_to_int_is_not_coerced(t *testing.T) {
+	var val int
+	err := UnmarshalFromString(`"100"`, &val)
+	if err == nil {
+		t.Fatal("expected an error decoding a JSON string into an int without Fuzzy set")
+	}
+}
@@ -0,0 +1,109 @@
+package jsoniter
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/json-iterator/go/require"
+)
+
+type testMarshalerObject struct {
+	val int
+}
+
+func (o testMarshalerObject) MarshalJSON() ([]byte, error) {
+	return []byte(`"wrapped"`), nil
+}
+
+func (o *testMarshalerObject) UnmarshalJSON(data []byte) error {
+	o.val = len(data)
+	return nil
+}
+
+type testTextMarshalerObject struct {
+	val string
+}
+
+func (o testTextMarshalerObject) MarshalText() ([]byte, error) {
+	return []byte("text:" + o.val), nil
+}
+
+func (o *testTextMarshalerObject) UnmarshalText(data []byte) error {
+	o.val = string(data)
+	return nil
+}
+
+func Test_encode_json_marshaler(t *testing.T) {
+	should := require.New(t)
+	str, err := MarshalToString(testMarshalerObject{})
+	should.Nil(err)
+	should.Equal(`"wrapped"`, str)
+}
+
+func Test_decode_json_unmarshaler(t *testing.T) {
+	should := require.New(t)
+	obj := testMarshalerObject{}
+	should.Nil(UnmarshalFromString(`"hello"`, &obj))
+	should.Equal(len(`"hello"`), obj.val)
+}
+
+func Test_encode_text_marshaler(t *testing.T) {
+	should := require.New(t)
+	str, err := MarshalToString(testTextMarshalerObject{val: "hello"})
+	should.Nil(err)
+	should.Equal(`"text:hello"`, str)
+}
+
+func Test_decode_text_unmarshaler(t *testing.T) {
+	should := require.New(t)
+	obj := testTextMarshalerObject{}
+	should.Nil(UnmarshalFromString(`"hello"`, &obj))
+	should.Equal("hello", obj.val)
+}
+
+// testPtrMarshalerObject implements json.Marshaler/Unmarshaler with a
+// pointer receiver only, so *testPtrMarshalerObject satisfies the
+// interfaces but testPtrMarshalerObject itself does not.
+type testPtrMarshalerObject struct {
+	val int
+}
+
+func (o *testPtrMarshalerObject) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Itoa(o.val)), nil
+}
+
+func (o *testPtrMarshalerObject) UnmarshalJSON(data []byte) error {
+	val, err := strconv.Atoi(string(data))
+	if err != nil {
+		return err
+	}
+	o.val = val
+	return nil
+}
+
+func Test_encode_ptr_receiver_marshaler_at_top_level(t *testing.T) {
+	should := require.New(t)
+	str, err := MarshalToString(&testPtrMarshalerObject{val: 42})
+	should.Nil(err)
+	should.Equal("42", str)
+}
+
+func Test_encode_ptr_receiver_marshaler_struct_field(t *testing.T) {
+	should := require.New(t)
+	type Wrapper struct {
+		Obj *testPtrMarshalerObject
+	}
+	str, err := MarshalToString(Wrapper{Obj: &testPtrMarshalerObject{val: 7}})
+	should.Nil(err)
+	should.Equal(`{"Obj":7}`, str)
+}
+
+func Test_decode_ptr_receiver_unmarshaler_struct_field(t *testing.T) {
+	should := require.New(t)
+	type Wrapper struct {
+		Obj *testPtrMarshalerObject
+	}
+	w := Wrapper{}
+	should.Nil(UnmarshalFromString(`{"Obj":42}`, &w))
+	should.Equal(42, w.Obj.val)
+}
@@ -0,0 +1,35 @@
+package jsoniter
+
+import (
+	"testing"
+
+	"github.com/json-iterator/go/require"
+)
+
+func Test_decode_slice_of_int(t *testing.T) {
+	should := require.New(t)
+	var val []int
+	should.Nil(UnmarshalFromString("[1,2,3]", &val))
+	should.Equal([]int{1, 2, 3}, val)
+}
+
+func Test_decode_slice_grows_past_initial_capacity(t *testing.T) {
+	should := require.New(t)
+	val := make([]int, 0, 1)
+	should.Nil(UnmarshalFromString("[1,2,3,4,5]", &val))
+	should.Equal([]int{1, 2, 3, 4, 5}, val)
+}
+
+func Test_decode_slice_reuses_existing_elements(t *testing.T) {
+	should := require.New(t)
+	val := []int{9, 9, 9}
+	should.Nil(UnmarshalFromString("[1,2]", &val))
+	should.Equal([]int{1, 2}, val)
+}
+
+func Test_decode_empty_slice(t *testing.T) {
+	should := require.New(t)
+	val := []int{1, 2, 3}
+	should.Nil(UnmarshalFromString("[]", &val))
+	should.Equal([]int{}, val)
+}
@@ -0,0 +1,286 @@
+package jsoniter
+
+import (
+	"io"
+	"reflect"
+	"strconv"
+	"unsafe"
+)
+
+// Stream is a low-level JSON writer. It is the building block the
+// reflection-based Encoder tree writes through, mirroring how Iterator is
+// the building block the decoder tree reads through.
+type Stream struct {
+	cfg       *frozenConfig
+	out       io.Writer
+	buf       []byte
+	indention int
+	Error     error
+}
+
+// NewStream creates a Stream bound to cfg, buffering into a []byte of the
+// given initial capacity. out may be nil, in which case Buffer returns the
+// accumulated bytes instead of Flush writing them out.
+func NewStream(cfg *frozenConfig, out io.Writer, bufSize int) *Stream {
+	return &Stream{cfg: cfg, out: out, buf: make([]byte, 0, bufSize)}
+}
+
+// Reset rewinds the Stream to write to out from an empty buffer.
+func (stream *Stream) Reset(out io.Writer) {
+	stream.out = out
+	stream.buf = stream.buf[:0]
+}
+
+// Buffer returns the bytes written so far.
+func (stream *Stream) Buffer() []byte {
+	return stream.buf
+}
+
+// Flush writes the buffered bytes to the underlying io.Writer, if any.
+func (stream *Stream) Flush() error {
+	if stream.out == nil {
+		return nil
+	}
+	_, err := stream.out.Write(stream.buf)
+	stream.buf = stream.buf[:0]
+	return err
+}
+
+// WriteRaw appends s to the output unescaped.
+func (stream *Stream) WriteRaw(s string) {
+	stream.buf = append(stream.buf, s...)
+}
+
+func (stream *Stream) writeByte(c byte) {
+	stream.buf = append(stream.buf, c)
+}
+
+// WriteNil writes the JSON literal null.
+func (stream *Stream) WriteNil() {
+	stream.WriteRaw("null")
+}
+
+// WriteTrue writes the JSON literal true.
+func (stream *Stream) WriteTrue() {
+	stream.WriteRaw("true")
+}
+
+// WriteFalse writes the JSON literal false.
+func (stream *Stream) WriteFalse() {
+	stream.WriteRaw("false")
+}
+
+// WriteBool writes the JSON literal true or false.
+func (stream *Stream) WriteBool(val bool) {
+	if val {
+		stream.WriteTrue()
+	} else {
+		stream.WriteFalse()
+	}
+}
+
+// indentionStep returns the configured IndentionStep, or 0 if stream has no
+// cfg (e.g. a zero-value Stream used directly in tests).
+func (stream *Stream) indentionStep() int {
+	if stream.cfg == nil {
+		return 0
+	}
+	return stream.cfg.indentionStep
+}
+
+// writeIndention appends a newline followed by indention*step spaces, if
+// indentation is configured; it is a no-op otherwise.
+func (stream *Stream) writeIndention(delta int) {
+	step := stream.indentionStep()
+	if step <= 0 {
+		return
+	}
+	stream.buf = append(stream.buf, '\n')
+	for i := 0; i < (stream.indention+delta)*step; i++ {
+		stream.buf = append(stream.buf, ' ')
+	}
+}
+
+// WriteObjectStart writes the opening brace of a JSON object.
+func (stream *Stream) WriteObjectStart() {
+	stream.writeByte('{')
+	stream.indention++
+	stream.writeIndention(0)
+}
+
+// WriteObjectEnd writes the closing brace of a JSON object.
+func (stream *Stream) WriteObjectEnd() {
+	stream.indention--
+	stream.writeIndention(0)
+	stream.writeByte('}')
+}
+
+// WriteArrayStart writes the opening bracket of a JSON array.
+func (stream *Stream) WriteArrayStart() {
+	stream.writeByte('[')
+	stream.indention++
+	stream.writeIndention(0)
+}
+
+// WriteArrayEnd writes the closing bracket of a JSON array.
+func (stream *Stream) WriteArrayEnd() {
+	stream.indention--
+	stream.writeIndention(0)
+	stream.writeByte(']')
+}
+
+// WriteMore writes the comma separating two elements of an object or array.
+func (stream *Stream) WriteMore() {
+	stream.writeByte(',')
+	stream.writeIndention(0)
+}
+
+// WriteObjectField writes a quoted object field name followed by a colon.
+func (stream *Stream) WriteObjectField(field string) {
+	stream.WriteString(field)
+	stream.writeByte(':')
+	if stream.indentionStep() > 0 {
+		stream.writeByte(' ')
+	}
+}
+
+// WriteInt writes val as a JSON number.
+func (stream *Stream) WriteInt(val int) {
+	stream.buf = strconv.AppendInt(stream.buf, int64(val), 10)
+}
+
+// WriteInt8 writes val as a JSON number.
+func (stream *Stream) WriteInt8(val int8) {
+	stream.buf = strconv.AppendInt(stream.buf, int64(val), 10)
+}
+
+// WriteInt16 writes val as a JSON number.
+func (stream *Stream) WriteInt16(val int16) {
+	stream.buf = strconv.AppendInt(stream.buf, int64(val), 10)
+}
+
+// WriteInt32 writes val as a JSON number.
+func (stream *Stream) WriteInt32(val int32) {
+	stream.buf = strconv.AppendInt(stream.buf, int64(val), 10)
+}
+
+// WriteInt64 writes val as a JSON number.
+func (stream *Stream) WriteInt64(val int64) {
+	stream.buf = strconv.AppendInt(stream.buf, val, 10)
+}
+
+// WriteUint writes val as a JSON number.
+func (stream *Stream) WriteUint(val uint) {
+	stream.buf = strconv.AppendUint(stream.buf, uint64(val), 10)
+}
+
+// WriteUint8 writes val as a JSON number.
+func (stream *Stream) WriteUint8(val uint8) {
+	stream.buf = strconv.AppendUint(stream.buf, uint64(val), 10)
+}
+
+// WriteUint16 writes val as a JSON number.
+func (stream *Stream) WriteUint16(val uint16) {
+	stream.buf = strconv.AppendUint(stream.buf, uint64(val), 10)
+}
+
+// WriteUint32 writes val as a JSON number.
+func (stream *Stream) WriteUint32(val uint32) {
+	stream.buf = strconv.AppendUint(stream.buf, uint64(val), 10)
+}
+
+// WriteUint64 writes val as a JSON number.
+func (stream *Stream) WriteUint64(val uint64) {
+	stream.buf = strconv.AppendUint(stream.buf, val, 10)
+}
+
+// WriteFloat32 writes val as a JSON number, honoring
+// Config.MarshalFloatWith6Digits.
+func (stream *Stream) WriteFloat32(val float32) {
+	if stream.cfg != nil && stream.cfg.configBeforeFrozen.MarshalFloatWith6Digits {
+		stream.buf = strconv.AppendFloat(stream.buf, float64(val), 'f', 6, 32)
+		return
+	}
+	stream.buf = strconv.AppendFloat(stream.buf, float64(val), 'g', -1, 32)
+}
+
+// WriteFloat64 writes val as a JSON number, honoring
+// Config.MarshalFloatWith6Digits.
+func (stream *Stream) WriteFloat64(val float64) {
+	if stream.cfg != nil && stream.cfg.configBeforeFrozen.MarshalFloatWith6Digits {
+		stream.buf = strconv.AppendFloat(stream.buf, val, 'f', 6, 64)
+		return
+	}
+	stream.buf = strconv.AppendFloat(stream.buf, val, 'g', -1, 64)
+}
+
+// WriteString writes val as a quoted, escaped JSON string. '<', '>' and '&'
+// are only escaped when Config.EscapeHTML is set, matching encoding/json.
+func (stream *Stream) WriteString(val string) {
+	stream.writeByte('"')
+	for i := 0; i < len(val); i++ {
+		c := val[i]
+		switch c {
+		case '"':
+			stream.WriteRaw(`\"`)
+		case '\\':
+			stream.WriteRaw(`\\`)
+		case '\n':
+			stream.WriteRaw(`\n`)
+		case '\t':
+			stream.WriteRaw(`\t`)
+		case '\r':
+			stream.WriteRaw(`\r`)
+		case '<', '>', '&':
+			if stream.cfg != nil && stream.cfg.configBeforeFrozen.EscapeHTML {
+				stream.writeEscapedByte(c)
+			} else {
+				stream.writeByte(c)
+			}
+		default:
+			if c < 0x20 {
+				stream.writeEscapedByte(c)
+			} else {
+				stream.writeByte(c)
+			}
+		}
+	}
+	stream.writeByte('"')
+}
+
+func (stream *Stream) writeEscapedByte(c byte) {
+	const hex = "0123456789abcdef"
+	stream.WriteRaw(`\u00`)
+	stream.buf = append(stream.buf, hex[c>>4], hex[c&0xf])
+}
+
+// WriteVal encodes val using reflection, caching the encoder it builds the
+// same way Iterator.Read caches decoders.
+func (stream *Stream) WriteVal(val interface{}) {
+	if val == nil {
+		stream.WriteNil()
+		return
+	}
+	cfg := stream.cfg
+	if cfg == nil {
+		cfg = ConfigDefault.(*frozenConfig)
+	}
+	typ := reflect.TypeOf(val)
+	cachedEncoder := cfg.getEncoderFromCache(typ)
+	if cachedEncoder == nil {
+		encoder, err := cfg.encoderOfType(typ)
+		if err != nil {
+			stream.Error = err
+			return
+		}
+		cachedEncoder = encoder
+		cfg.addEncoderToCache(typ, encoder)
+	}
+	// For pointer-shaped types (pointers, maps, and structs or arrays holding
+	// a single pointer) the boxed word IS the value rather than its address,
+	// but every encoder expects the address of the value, so copy val into
+	// freshly allocated storage and hand over that address instead.
+	holder := reflect.New(typ)
+	holder.Elem().Set(reflect.ValueOf(val))
+	cachedEncoder.encode(unsafe.Pointer(holder.Pointer()), stream)
+}